@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/go-kit/kit/log"
+	"k8s.io/client-go/rest"
+
+	"github.com/fluxcd/helm-operator/pkg/release"
+)
+
+// annotationConcurrency bounds how many resources the operator
+// annotates with the antecedent annotation (and, on takeover, the
+// Helm 3 ownership markers) at the same time; see
+// `release.DefaultAnnotationConcurrency`.
+var annotationConcurrency = flag.Int("annotation-concurrency", release.DefaultAnnotationConcurrency,
+	"maximum number of resources to annotate with the antecedent annotation concurrently per release")
+
+func main() {
+	flag.Parse()
+
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	kubeConfig, err := rest.InClusterConfig()
+	if err != nil {
+		logger.Log("error", "failed to load in-cluster config", "err", err)
+		return
+	}
+
+	pool, err := release.NewClientPool(kubeConfig)
+	if err != nil {
+		logger.Log("error", "failed to build client pool", "err", err)
+		return
+	}
+
+	// The manager, CRD informers and HelmRelease controller that drive
+	// reconciliation aren't part of this checkout; they construct a
+	// `*helm.Release` and a `HelmRelease` per reconcile and call
+	// `release.Annotate(logger, pool, hr, rel, resourceID,
+	// *annotationConcurrency)` with the flag value above.
+	_ = pool
+}
@@ -0,0 +1,77 @@
+// Package v1 holds the subset of the HelmRelease custom resource
+// definition that `pkg/release` needs to translate user-facing spec
+// fields into the behaviour of its antecedent annotation logic.
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HelmRelease is a custom resource describing a Helm release that
+// should be maintained by the operator.
+type HelmRelease struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HelmReleaseSpec   `json:"spec"`
+	Status HelmReleaseStatus `json:"status,omitempty"`
+}
+
+// HelmReleaseStatus holds the observed state of a HelmRelease.
+type HelmReleaseStatus struct {
+	// Conditions holds the latest available observations of the
+	// HelmRelease's state.
+	// +optional
+	Conditions []HelmReleaseCondition `json:"conditions,omitempty"`
+}
+
+// HelmReleaseConditionType is a valid value for HelmReleaseCondition.Type.
+type HelmReleaseConditionType string
+
+// HelmReleaseResourceOwnershipConflict is set to `ConditionTrue` when
+// one or more of the release's resources are already owned by a
+// different Helm release and `Spec.AdoptResources` doesn't allow
+// taking them over; see `release.ResourceOwnershipConflict`.
+const HelmReleaseResourceOwnershipConflict HelmReleaseConditionType = "ResourceOwnershipConflict"
+
+// HelmReleaseManifestInvalid is set to `ConditionTrue` when the
+// release's manifest (or one of its hooks') could not be parsed into
+// Kubernetes objects, so ownership/antecedent tracking could not be
+// performed for any of the release's resources.
+const HelmReleaseManifestInvalid HelmReleaseConditionType = "ManifestInvalid"
+
+// HelmReleaseCondition describes the state of a HelmRelease at a
+// certain point.
+type HelmReleaseCondition struct {
+	Type               HelmReleaseConditionType `json:"type"`
+	Status             corev1.ConditionStatus   `json:"status"`
+	LastTransitionTime metav1.Time              `json:"lastTransitionTime,omitempty"`
+	Reason             string                   `json:"reason,omitempty"`
+	Message            string                   `json:"message,omitempty"`
+}
+
+// HelmReleaseSpec holds the user-facing configuration of a
+// HelmRelease.
+type HelmReleaseSpec struct {
+	// AdoptResources controls whether the operator is allowed to take
+	// over resources that already exist in the cluster but are not
+	// yet tracked by this HelmRelease's antecedent annotation.
+	//
+	//   - "false" (the default): resources already managed by a
+	//     different Helm release (detected through Helm 3's own
+	//     `app.kubernetes.io/managed-by`/`meta.helm.sh/release-*`
+	//     markers) are left alone and reported as ownership
+	//     conflicts; resources with no ownership markers at all are
+	//     still adopted.
+	//   - "strict": also takes over resources owned by a different
+	//     Helm release, but still refuses a resource our own
+	//     antecedent annotation says belongs to a different
+	//     HelmRelease.
+	//   - "true": takes over any resource regardless of what either
+	//     ownership scheme says it belongs to.
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=false;true;strict
+	AdoptResources string `json:"adoptResources,omitempty"`
+}
@@ -1,27 +1,30 @@
 package release
 
 import (
+	"encoding/json"
 	"fmt"
+	"sync"
 
 	"github.com/fluxcd/flux/pkg/resource"
 	"github.com/ghodss/yaml"
 	"github.com/go-kit/kit/log"
 
+	helmrelease "helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/releaseutil"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/client-go/discovery"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 
+	v1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
 	"github.com/fluxcd/helm-operator/pkg/helm"
 )
 
@@ -32,29 +35,306 @@ import (
 // be a serialised `resource.ID`.
 const AntecedentAnnotation = "helm.fluxcd.io/antecedent"
 
+// The following are the standard labels and annotations Helm 3 itself
+// writes onto every resource it installs (see
+// `helm.sh/helm/v3/pkg/storage/driver.ManagedByHelm`). We inspect these
+// when an object has no antecedent annotation, so that resources which
+// already belong to an unrelated Helm release are not silently taken
+// over by a HelmRelease targeting the same cluster-scoped name.
+const (
+	helmManagedByLabel             = "app.kubernetes.io/managed-by"
+	helmManagedByValue             = "Helm"
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+)
+
+// AdoptionPolicy determines how `annotatedWithResourceID` behaves when
+// it encounters a resource that is not yet tracked by any HelmRelease,
+// but is already owned by another Helm release (Helm 3 style, detected
+// through the `helmManagedByLabel`/`helmReleaseName*Annotation` trio)
+// or has no ownership markers at all.
+type AdoptionPolicy string
+
+const (
+	// AdoptionDisabled refuses to take over any resource that carries
+	// Helm 3 ownership markers for a different release. Resources with
+	// no ownership markers at all (e.g. created by `kubectl apply`) are
+	// still adopted, matching the historical behaviour of this package.
+	AdoptionDisabled AdoptionPolicy = "false"
+	// AdoptionEnabled takes over resources owned by a different Helm
+	// release, mirroring how `helm upgrade --take-ownership` adopts
+	// pre-existing objects in Helm 3.
+	AdoptionEnabled AdoptionPolicy = "true"
+	// AdoptionStrict behaves like AdoptionEnabled, but additionally
+	// requires the resource to carry no antecedent annotation from a
+	// *different* HelmRelease before it will be taken over; this is the
+	// safer choice when HelmReleases across namespaces may race for the
+	// same cluster-scoped resource.
+	AdoptionStrict AdoptionPolicy = "strict"
+)
+
+// AdoptionPolicyFromSpec translates a `HelmReleaseSpec.AdoptResources`
+// value into an `AdoptionPolicy`. An empty or unrecognised value is
+// treated as `AdoptionDisabled`, matching the field's documented
+// default.
+func AdoptionPolicyFromSpec(adoptResources string) AdoptionPolicy {
+	switch AdoptionPolicy(adoptResources) {
+	case AdoptionEnabled:
+		return AdoptionEnabled
+	case AdoptionStrict:
+		return AdoptionStrict
+	default:
+		return AdoptionDisabled
+	}
+}
+
+// Annotate is the entry point a HelmRelease reconciler uses to stamp
+// the antecedent annotation (and, on takeover, the Helm 3 ownership
+// markers) onto `rel`'s resources, reading the adoption policy
+// straight off `hr.Spec.AdoptResources`. `concurrency` is normally the
+// operator's `--annotation-concurrency` flag value.
+func Annotate(logger log.Logger, pool *ClientPool, hr *v1.HelmRelease, rel *helm.Release, resourceID resource.ID, concurrency int) error {
+	return annotateWithResourceID(logger, pool, rel, resourceID, AdoptionPolicyFromSpec(hr.Spec.AdoptResources), concurrency)
+}
+
+// CheckAntecedent is the entry point a HelmRelease reconciler uses to
+// decide whether it may take ownership of `rel`'s resources: it reads
+// the adoption policy straight off `hr.Spec.AdoptResources`, reports
+// any conflicts it finds as Warning events on `hr` through `recorder`
+// (which may be nil to skip event emission), and mirrors them onto
+// `hr.Status`'s `ResourceOwnershipConflict` condition so the result is
+// visible via `kubectl describe hr` even between reconciles. A
+// manifest that can't be parsed at all (see `manifestsForRelease`) is
+// mirrored the same way onto the `ManifestInvalid` condition instead,
+// since there's nothing to check ownership of. See
+// `annotatedWithResourceID` for the semantics of the returned values.
+func CheckAntecedent(pool *ClientPool, hr *v1.HelmRelease, rel *helm.Release, resourceID resource.ID, recorder record.EventRecorder) (bool, string, error) {
+	ok, v, err := annotatedWithResourceID(pool, rel, resourceID, AdoptionPolicyFromSpec(hr.Spec.AdoptResources), recorder, hr)
+	applyAntecedentConditions(hr, err)
+	return ok, v, err
+}
+
+// applyAntecedentConditions mirrors the outcome of
+// `annotatedWithResourceID` onto `hr.Status`: an `ownershipConflicts`
+// error sets `ResourceOwnershipConflict`, any other non-nil error
+// (typically a malformed manifest, see `manifestsForRelease`) sets
+// `ManifestInvalid`, and a nil error clears both.
+func applyAntecedentConditions(hr *v1.HelmRelease, err error) {
+	switch conflicts, isConflict := err.(ownershipConflicts); {
+	case isConflict:
+		message := conflicts.Error()
+		for _, c := range conflicts {
+			message += fmt.Sprintf("\n- %s %s/%s (current owner %q)", c.GroupVersionKind, c.Namespace, c.Name, c.CurrentOwner)
+		}
+		setCondition(hr, v1.HelmReleaseResourceOwnershipConflict, corev1.ConditionTrue, ReasonOwnershipConflict, message)
+		setCondition(hr, v1.HelmReleaseManifestInvalid, corev1.ConditionFalse, "", "")
+	case err == nil:
+		setCondition(hr, v1.HelmReleaseResourceOwnershipConflict, corev1.ConditionFalse, "", "")
+		setCondition(hr, v1.HelmReleaseManifestInvalid, corev1.ConditionFalse, "", "")
+	default:
+		setCondition(hr, v1.HelmReleaseManifestInvalid, corev1.ConditionTrue, ReasonManifestInvalid, err.Error())
+	}
+}
+
+// ReasonManifestInvalid is the reason used on the HelmRelease's
+// `ManifestInvalid` status condition when its manifest (or one of its
+// hooks') could not be parsed into Kubernetes objects.
+const ReasonManifestInvalid = "ManifestInvalid"
+
+// setCondition creates or updates the condition of the given
+// `conditionType` on `hr.Status`, preserving `LastTransitionTime`
+// when the status is unchanged from what's already there.
+func setCondition(hr *v1.HelmRelease, conditionType v1.HelmReleaseConditionType, status corev1.ConditionStatus, reason, message string) {
+	condition := v1.HelmReleaseCondition{
+		Type:               conditionType,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+	for i := range hr.Status.Conditions {
+		if hr.Status.Conditions[i].Type == condition.Type {
+			if hr.Status.Conditions[i].Status == condition.Status {
+				condition.LastTransitionTime = hr.Status.Conditions[i].LastTransitionTime
+			}
+			hr.Status.Conditions[i] = condition
+			return
+		}
+	}
+	hr.Status.Conditions = append(hr.Status.Conditions, condition)
+}
+
+// ReasonOwnershipConflict is the reason used on the Kubernetes Event
+// (and mirrored onto the HelmRelease's `ResourceOwnershipConflict`
+// status condition by the caller) emitted when a live resource is
+// found to be owned by a different Helm release.
+const ReasonOwnershipConflict = "OwnershipConflict"
+
+// ResourceOwnershipConflict describes a live resource whose Helm 3
+// ownership markers, or whose antecedent annotation, point at a
+// release other than the one being reconciled; `CurrentOwner` holds
+// either the `<namespace>/<name>` of that Helm release or the value of
+// the antecedent annotation. Callers are expected to mirror a slice of these onto
+// the HelmRelease's `ResourceOwnershipConflict` status condition, so
+// GitOps users have an actionable signal instead of a silent no-op.
+type ResourceOwnershipConflict struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+	CurrentOwner     string
+}
+
+// ownershipConflicts is returned from `annotatedWithResourceID` when
+// one or more resources are owned by a different Helm release and
+// `policy` doesn't allow taking them over.
+type ownershipConflicts []ResourceOwnershipConflict
+
+func (c ownershipConflicts) Error() string {
+	first := c[0]
+	if len(c) == 1 {
+		return fmt.Sprintf("resource %s %s/%s is already managed by Helm release %q, refusing to take over (set adoptResources to override)",
+			first.GroupVersionKind.Kind, first.Namespace, first.Name, first.CurrentOwner)
+	}
+	return fmt.Sprintf("%d resources are already managed by other Helm releases, refusing to take over (set adoptResources to override); first conflict: %s %s/%s is managed by %q",
+		len(c), first.GroupVersionKind.Kind, first.Namespace, first.Name, first.CurrentOwner)
+}
+
+// emitOwnershipConflictEvent records a Warning event on `object`
+// through `recorder` for a detected ownership conflict. `recorder`
+// and `object` may be nil, in which case no event is emitted; this
+// lets callers that predate event support keep working unchanged.
+func emitOwnershipConflictEvent(recorder record.EventRecorder, object runtime.Object, conflict ResourceOwnershipConflict) {
+	if recorder == nil || object == nil {
+		return
+	}
+	recorder.Eventf(object, corev1.EventTypeWarning, ReasonOwnershipConflict,
+		"resource %s %s/%s is already managed by Helm release %q",
+		conflict.GroupVersionKind.Kind, conflict.Namespace, conflict.Name, conflict.CurrentOwner)
+}
+
+// helmOwnedByOther inspects the standard Helm 3 ownership markers on a
+// live object and reports the `<namespace>/<name>` of the Helm release
+// that owns it, if that release is not `releaseName`/`releaseNamespace`.
+// It returns an empty string when the object carries no Helm 3 markers,
+// or when they agree with the given release.
+func helmOwnedByOther(res *unstructured.Unstructured, releaseName, releaseNamespace string) string {
+	if res.GetLabels()[helmManagedByLabel] != helmManagedByValue {
+		return ""
+	}
+	annotations := res.GetAnnotations()
+	name, namespace := annotations[helmReleaseNameAnnotation], annotations[helmReleaseNamespaceAnnotation]
+	if name == "" && namespace == "" {
+		return ""
+	}
+	if name == releaseName && namespace == releaseNamespace {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
+
+// evaluateResourceOwnership applies the antecedent-annotation and
+// Helm 3 ownership-marker checks described on `annotatedWithResourceID`
+// to a single already-fetched resource. It reports whether the
+// resource already carries our antecedent annotation, and a non-nil
+// conflict if the resource is tracked by a different owner and
+// `policy` doesn't allow taking it over.
+func evaluateResourceOwnership(res *unstructured.Unstructured, rel *helm.Release, resourceID resource.ID, policy AdoptionPolicy) (matched bool, conflict *ResourceOwnershipConflict) {
+	if v, ok := res.GetAnnotations()[AntecedentAnnotation]; ok {
+		if v == resourceID.String() {
+			return true, nil
+		}
+		// The resource is already tracked as belonging to a different
+		// HelmRelease. AdoptionStrict respects that tracking like the
+		// historical behaviour; only AdoptionEnabled forces through a
+		// takeover regardless of what our own antecedent annotation says.
+		if policy != AdoptionEnabled {
+			return false, &ResourceOwnershipConflict{
+				GroupVersionKind: res.GroupVersionKind(),
+				Namespace:        res.GetNamespace(),
+				Name:             res.GetName(),
+				CurrentOwner:     v,
+			}
+		}
+		return false, nil
+	}
+
+	if owner := helmOwnedByOther(res, rel.Name, rel.Namespace); owner != "" {
+		if policy != AdoptionEnabled && policy != AdoptionStrict {
+			return false, &ResourceOwnershipConflict{
+				GroupVersionKind: res.GroupVersionKind(),
+				Namespace:        res.GetNamespace(),
+				Name:             res.GetName(),
+				CurrentOwner:     owner,
+			}
+		}
+	}
+	return false, nil
+}
+
+// evaluateResourcesOwnership runs `evaluateResourceOwnership` over
+// every already-fetched resource and aggregates the result: it
+// reports that the release as a whole is annotated only if every
+// resource matched, and collects conflicts from *all* resources
+// rather than stopping at the first match or the first conflict —
+// see `annotatedWithResourceID` for why that matters. Every conflict
+// found is also reported through `recorder` as a Warning event on
+// `eventObject`, which like `recorder` may be nil to skip event
+// emission.
+func evaluateResourcesOwnership(resources []*unstructured.Unstructured, rel *helm.Release, resourceID resource.ID, policy AdoptionPolicy, recorder record.EventRecorder, eventObject runtime.Object) (bool, string, error) {
+	var conflicts ownershipConflicts
+	annotated := true
+	for _, res := range resources {
+		matched, conflict := evaluateResourceOwnership(res, rel, resourceID, policy)
+		if !matched {
+			annotated = false
+		}
+		if conflict != nil {
+			emitOwnershipConflictEvent(recorder, eventObject, *conflict)
+			conflicts = append(conflicts, *conflict)
+		}
+	}
+	if len(conflicts) > 0 {
+		return false, "", conflicts
+	}
+	if annotated {
+		return true, resourceID.String(), nil
+	}
+	return false, "", nil
+}
+
 // annotatedWithResourceID determines if the resources of the given
 // `helm.Release` are annotated with the antecedent annotation with
 // a value that equals to the given `resource.ID`. It returns a
-// boolean indicating the presence of the annotation with the right
-// `resource.ID` and a string with the value of the annotation, or
-// an error.
+// boolean indicating that *every* resource in the release carries
+// the annotation with the right `resource.ID`, and a string with that
+// `resource.ID`, or an error.
 //
-// If there are no errors and no annotations were found either, it
-// assumes the release has been installed manually and we want to
-// take over.
-func annotatedWithResourceID(kubeConfig *rest.Config, rel *helm.Release, resourceID resource.ID) (bool, string, error) {
-	client, err  := dynamic.NewForConfig(kubeConfig)
+// Objects whose kind has no REST mapping on the cluster are skipped
+// and do not count towards that result. Every other object in the
+// release is fetched and evaluated, even once a
+// match or a conflict has been found on an earlier one: a
+// cluster-scoped resource that two HelmReleases are racing over is
+// just as likely to be the last object in the manifest as the first,
+// and an established release (whose objects already carry the
+// correct antecedent) must still have its later, not-yet-annotated
+// objects checked for conflicts with a different release. See
+// `evaluateResourcesOwnership` for how the per-object results are
+// aggregated, and `evaluateResourceOwnership` for the per-object
+// antecedent/Helm-3-ownership-marker checks themselves.
+func annotatedWithResourceID(pool *ClientPool, rel *helm.Release, resourceID resource.ID, policy AdoptionPolicy, recorder record.EventRecorder, eventObject runtime.Object) (bool, string, error) {
+	client, err := pool.DynamicClient()
 	if err != nil {
 		return false, "", err
 	}
-	restMap, err := buildDiscoveryRestMapper(kubeConfig)
+
+	objs, err := manifestsForRelease(rel, log.NewNopLogger())
 	if err != nil {
 		return false, "", err
 	}
 
-	objs := releaseManifestToUnstructured(rel.Manifest, log.NewNopLogger())
+	var resources []*unstructured.Unstructured
 	for _, obj := range objs {
-		mapping, err := restMap.RESTMapping(obj.GroupVersionKind().GroupKind(), obj.GroupVersionKind().Version)
+		mapping, err := pool.RESTMapping(obj.GroupVersionKind().GroupKind(), obj.GroupVersionKind().Version)
 		if err != nil {
 			continue
 		}
@@ -63,56 +343,80 @@ func annotatedWithResourceID(kubeConfig *rest.Config, rel *helm.Release, resourc
 			obj.SetNamespace(rel.Namespace)
 		}
 
-		{
-			var res *unstructured.Unstructured
-			var err error
-			wait.ExponentialBackoff(retry.DefaultBackoff, func() (bool, error) {
-				res, err = client.Resource(mapping.Resource).Namespace(obj.GetNamespace()).Get(obj.GetName(), metav1.GetOptions{})
-				// All these errors indicate a transient error that should
-				// be retried.
-				if net.IsConnectionReset(err) || errors.IsInternalError(err) || errors.IsTimeout(err) || errors.IsTooManyRequests(err) {
-					return false, nil
-				}
-				// Checks for a Retry-After header, the presence of this
-				// header is an explicit signal we should retry.
-				if _, shouldRetry := errors.SuggestsClientDelay(err); shouldRetry {
-					return false, nil
-				}
-				if err != nil {
-					return false, err
-				}
-				return true, nil
-			})
-
-			if err != nil {
-				return false, "", err
+		var res *unstructured.Unstructured
+		var getErr error
+		wait.ExponentialBackoff(retry.DefaultBackoff, func() (bool, error) {
+			res, getErr = client.Resource(mapping.Resource).Namespace(obj.GetNamespace()).Get(obj.GetName(), metav1.GetOptions{})
+			// All these errors indicate a transient error that should
+			// be retried.
+			if net.IsConnectionReset(getErr) || errors.IsInternalError(getErr) || errors.IsTimeout(getErr) || errors.IsTooManyRequests(getErr) {
+				return false, nil
 			}
-
-			if v, ok := res.GetAnnotations()[AntecedentAnnotation]; ok {
-				return v == resourceID.String(), v, nil
+			// Checks for a Retry-After header, the presence of this
+			// header is an explicit signal we should retry.
+			if _, shouldRetry := errors.SuggestsClientDelay(getErr); shouldRetry {
+				return false, nil
 			}
+			if getErr != nil {
+				return false, getErr
+			}
+			return true, nil
+		})
+
+		if getErr != nil {
+			return false, "", getErr
 		}
+
+		resources = append(resources, res)
 	}
-	return true, "", nil
+
+	return evaluateResourcesOwnership(resources, rel, resourceID, policy, recorder, eventObject)
 }
 
+// antecedentFieldManager is the field manager used when server-side
+// applying the antecedent annotation (and, on takeover, the Helm 3
+// ownership markers). Owning these fields under a distinct manager
+// means our apply never strips fields Helm's own `helm` manager owns
+// on the same object, and vice versa.
+const antecedentFieldManager = "helm-operator/antecedent"
+
+// DefaultAnnotationConcurrency is the number of resources that are
+// annotated concurrently by `annotateWithResourceID` when the
+// operator is not configured with a `--annotation-concurrency` flag
+// value.
+const DefaultAnnotationConcurrency = 4
+
 // annotateWithResourceID annotates all of the resources in the given
 // `helm.Release` with a antecedent annotation holding the provided
-// `resource.ID`.
-func annotateWithResourceID(logger log.Logger, kubeConfig *rest.Config, rel *helm.Release, resourceID resource.ID) error {
-	client, err  := dynamic.NewForConfig(kubeConfig)
+// `resource.ID`, using server-side apply so the fields are owned by
+// `antecedentFieldManager` rather than contending with Helm's own
+// writes on subsequent upgrades. When `policy` is `AdoptionEnabled`
+// or `AdoptionStrict` it also stamps the Helm 3 ownership labels and
+// annotations onto the resource, so that a takeover is reflected
+// consistently in both ownership schemes rather than just ours.
+//
+// Up to `concurrency` resources are annotated at the same time; pass
+// `DefaultAnnotationConcurrency` to use the operator's default.
+func annotateWithResourceID(logger log.Logger, pool *ClientPool, rel *helm.Release, resourceID resource.ID, policy AdoptionPolicy, concurrency int) error {
+	client, err := pool.DynamicClient()
 	if err != nil {
 		return err
 	}
-	restMap, err := buildDiscoveryRestMapper(kubeConfig)
+
+	if concurrency <= 0 {
+		concurrency = DefaultAnnotationConcurrency
+	}
+
+	objs, err := manifestsForRelease(rel, logger)
 	if err != nil {
 		return err
 	}
 
-	annotation := []byte(`{"metadata":{"annotations":{"`+AntecedentAnnotation+`":"`+resourceID.String()+`"}}}`)
-	objs := releaseManifestToUnstructured(rel.Manifest, logger)
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
 	for _, obj := range objs {
-		mapping, err := restMap.RESTMapping(obj.GroupVersionKind().GroupKind(), obj.GroupVersionKind().Version)
+		obj := obj
+		mapping, err := pool.RESTMapping(obj.GroupVersionKind().GroupKind(), obj.GroupVersionKind().Version)
 		if err != nil {
 			logger.Log("error", fmt.Sprintf("failed to get REST mapping for group version kind: %#v", obj.GroupVersionKind()), "err", err)
 			continue
@@ -122,23 +426,143 @@ func annotateWithResourceID(logger log.Logger, kubeConfig *rest.Config, rel *hel
 			obj.SetNamespace(rel.Namespace)
 		}
 
-		if _, err := client.Resource(mapping.Resource).Namespace(obj.GetNamespace()).Patch(obj.GetName(), types.MergePatchType, annotation, metav1.PatchOptions{}); err != nil {
-			logger.Log("error", fmt.Sprintf("failed to mark resource '%s/%s' with antecedent annotation", obj.GetKind(), obj.GetName()), "err", err)
-		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			resourceClient := client.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+
+			if current, err := resourceClient.Get(obj.GetName(), metav1.GetOptions{}); err == nil {
+				if current.GetAnnotations()[AntecedentAnnotation] == resourceID.String() && policy == AdoptionDisabled {
+					return
+				}
+			}
+
+			apply := applyAnnotationObject(obj, resourceID, policy, rel.Name, rel.Namespace)
+			data, err := json.Marshal(apply)
+			if err != nil {
+				logger.Log("error", fmt.Sprintf("failed to marshal apply patch for resource '%s/%s'", obj.GetKind(), obj.GetName()), "err", err)
+				return
+			}
+
+			force := true
+			if _, err := resourceClient.Patch(obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{FieldManager: antecedentFieldManager, Force: &force}); err != nil {
+				logger.Log("error", fmt.Sprintf("failed to mark resource '%s/%s' with antecedent annotation", obj.GetKind(), obj.GetName()), "err", err)
+			}
+		}()
 	}
+	wg.Wait()
 	return nil
 }
 
+// applyAnnotationObject builds the minimal unstructured object used as
+// a server-side apply patch to stamp the antecedent annotation (and,
+// when adopting, the Helm 3 ownership markers) onto a resource.
+func applyAnnotationObject(obj unstructured.Unstructured, resourceID resource.ID, policy AdoptionPolicy, releaseName, releaseNamespace string) map[string]interface{} {
+	annotations := map[string]interface{}{
+		AntecedentAnnotation: resourceID.String(),
+	}
+	metadata := map[string]interface{}{
+		"name":        obj.GetName(),
+		"namespace":   obj.GetNamespace(),
+		"annotations": annotations,
+	}
+	if policy == AdoptionEnabled || policy == AdoptionStrict {
+		annotations[helmReleaseNameAnnotation] = releaseName
+		annotations[helmReleaseNamespaceAnnotation] = releaseNamespace
+		metadata["labels"] = map[string]interface{}{
+			helmManagedByLabel: helmManagedByValue,
+		}
+	}
+	return map[string]interface{}{
+		"apiVersion": obj.GetAPIVersion(),
+		"kind":       obj.GetKind(),
+		"metadata":   metadata,
+	}
+}
+
+// hooksAnnotated are the Helm lifecycle hook kinds whose resources we
+// also track with the antecedent annotation, so that ownership checks
+// and takeovers apply to them just as they do to the release's main
+// manifest. Hooks outside this set (e.g. pre/post-delete, pre/post-
+// rollback) either never coexist with a live HelmRelease reconcile or
+// are already gone by the time it runs, so annotating them would be
+// wasted work. Helm 3 has no "crd-install" hook kind any more - CRDs
+// ship through the chart's `crds/` directory instead.
+var hooksAnnotated = map[helmrelease.HookEvent]bool{
+	helmrelease.HookPreInstall:  true,
+	helmrelease.HookPostInstall: true,
+	helmrelease.HookTest:        true,
+}
+
+// manifestsForRelease returns the unstructured objects that should be
+// considered for antecedent annotation: the release's main manifest,
+// plus its lifecycle hook resources (for the hook events in
+// `hooksAnnotated`) that aren't deleted immediately after a successful
+// run. A malformed manifest is a structured error rather than a
+// skipped object, so `CheckAntecedent` can surface it through the
+// HelmRelease's `ManifestInvalid` status condition instead of
+// silently losing ownership tracking for the affected resources.
+func manifestsForRelease(rel *helm.Release, logger log.Logger) ([]unstructured.Unstructured, error) {
+	objs, err := releaseManifestToUnstructured(rel.Manifest, logger)
+	if err != nil {
+		return nil, fmt.Errorf("parsing release manifest: %w", err)
+	}
+
+	for _, hook := range rel.Hooks {
+		if !hookHasAnnotatedEvent(hook) || hookDeletedOnSuccess(hook) {
+			continue
+		}
+		hookObjs, err := releaseManifestToUnstructured(hook.Manifest, logger)
+		if err != nil {
+			return nil, fmt.Errorf("parsing manifest of hook %q: %w", hook.Name, err)
+		}
+		objs = append(objs, hookObjs...)
+	}
+	return objs, nil
+}
+
+// hookHasAnnotatedEvent reports whether any of the events a hook is
+// registered for is one we track with the antecedent annotation. A
+// chart commonly registers a single Job against more than one event
+// (e.g. `pre-upgrade,pre-install`), so all of `hook.Events` must be
+// considered rather than just the first.
+func hookHasAnnotatedEvent(hook *helmrelease.Hook) bool {
+	for _, event := range hook.Events {
+		if hooksAnnotated[event] {
+			return true
+		}
+	}
+	return false
+}
+
+// hookDeletedOnSuccess reports whether a hook is deleted immediately
+// once it completes successfully, via the structured
+// `DeletePolicies` Helm itself parses from the chart's
+// `helm.sh/hook-delete-policy` annotation; such a hook's resources
+// will no longer exist by the time the antecedent annotation is
+// reconciled.
+func hookDeletedOnSuccess(hook *helmrelease.Hook) bool {
+	for _, p := range hook.DeletePolicies {
+		if p == helmrelease.HookSucceeded {
+			return true
+		}
+	}
+	return false
+}
+
 // releaseManifestToUnstructured turns a string containing YAML
 // manifests into an array of Unstructured objects.
-func releaseManifestToUnstructured(manifest string, logger log.Logger) []unstructured.Unstructured {
+func releaseManifestToUnstructured(manifest string, logger log.Logger) ([]unstructured.Unstructured, error) {
 	manifests := releaseutil.SplitManifests(manifest)
 	var objs []unstructured.Unstructured
 	for _, manifest := range manifests {
 		var u unstructured.Unstructured
 
 		if err := yaml.Unmarshal([]byte(manifest), &u); err != nil {
-			continue
+			return nil, fmt.Errorf("unmarshalling manifest: %w", err)
 		}
 
 		// Helm charts may include list kinds, we are only interested in
@@ -146,8 +570,7 @@ func releaseManifestToUnstructured(manifest string, logger log.Logger) []unstruc
 		if u.IsList() {
 			l, err := u.ToList()
 			if err != nil {
-				logger.Log("err", err)
-				continue
+				return nil, fmt.Errorf("expanding list manifest: %w", err)
 			}
 			objs = append(objs, l.Items...)
 			continue
@@ -155,17 +578,5 @@ func releaseManifestToUnstructured(manifest string, logger log.Logger) []unstruc
 
 		objs = append(objs, u)
 	}
-	return objs
+	return objs, nil
 }
-
-func buildDiscoveryRestMapper(kubeConfig *rest.Config) (meta.RESTMapper, error) {
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(kubeConfig)
-	if err != nil {
-		return nil, err
-	}
-	groupResources, err := restmapper.GetAPIGroupResources(discoveryClient)
-	if err != nil {
-		return nil, err
-	}
-	return restmapper.NewDiscoveryRESTMapper(groupResources), nil
-}
\ No newline at end of file
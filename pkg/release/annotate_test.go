@@ -0,0 +1,330 @@
+package release
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fluxcd/flux/pkg/resource"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	v1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
+	"github.com/fluxcd/helm-operator/pkg/helm"
+)
+
+func TestAdoptionPolicyFromSpec(t *testing.T) {
+	for _, tt := range []struct {
+		adoptResources string
+		want           AdoptionPolicy
+	}{
+		{"", AdoptionDisabled},
+		{"false", AdoptionDisabled},
+		{"bogus", AdoptionDisabled},
+		{"true", AdoptionEnabled},
+		{"strict", AdoptionStrict},
+	} {
+		if got := AdoptionPolicyFromSpec(tt.adoptResources); got != tt.want {
+			t.Errorf("AdoptionPolicyFromSpec(%q) = %q, want %q", tt.adoptResources, got, tt.want)
+		}
+	}
+}
+
+func TestHelmOwnedByOther(t *testing.T) {
+	for _, tt := range []struct {
+		name                          string
+		labels, annotations           map[string]interface{}
+		releaseName, releaseNamespace string
+		want                          string
+	}{
+		{
+			name: "no markers at all",
+			want: "",
+		},
+		{
+			name:   "managed-by label without Helm value",
+			labels: map[string]interface{}{"app.kubernetes.io/managed-by": "kubectl"},
+			want:   "",
+		},
+		{
+			name:        "owned by the release in question",
+			labels:      map[string]interface{}{"app.kubernetes.io/managed-by": "Helm"},
+			annotations: map[string]interface{}{"meta.helm.sh/release-name": "foo", "meta.helm.sh/release-namespace": "default"},
+			releaseName: "foo", releaseNamespace: "default",
+			want: "",
+		},
+		{
+			name:        "owned by a different release",
+			labels:      map[string]interface{}{"app.kubernetes.io/managed-by": "Helm"},
+			annotations: map[string]interface{}{"meta.helm.sh/release-name": "bar", "meta.helm.sh/release-namespace": "other"},
+			releaseName: "foo", releaseNamespace: "default",
+			want: "other/bar",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &unstructured.Unstructured{Object: map[string]interface{}{"metadata": map[string]interface{}{}}}
+			if tt.labels != nil {
+				res.SetLabels(toStringMap(tt.labels))
+			}
+			if tt.annotations != nil {
+				res.SetAnnotations(toStringMap(tt.annotations))
+			}
+			if got := helmOwnedByOther(res, tt.releaseName, tt.releaseNamespace); got != tt.want {
+				t.Errorf("helmOwnedByOther() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func toStringMap(m map[string]interface{}) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v.(string)
+	}
+	return out
+}
+
+// TestEvaluateResourcesOwnership_ChecksEveryObject guards against the
+// bug where the ownership check stopped at the first object whose
+// antecedent annotation already matched: an established release's
+// first-listed resource nearly always matches, which used to mask a
+// genuine conflict on a later, cluster-scoped resource entirely.
+func TestEvaluateResourcesOwnership_ChecksEveryObject(t *testing.T) {
+	rel := &helm.Release{Name: "myrelease", Namespace: "myns"}
+	resourceID := resource.MakeID("myns", "helmrelease", "myrelease")
+
+	alreadyAnnotated := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "myrelease",
+			"namespace": "myns",
+			"annotations": map[string]interface{}{
+				AntecedentAnnotation: resourceID.String(),
+			},
+		},
+	}}
+	ownedByOtherRelease := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "ClusterRole",
+		"metadata": map[string]interface{}{
+			"name": "myrelease-role",
+			"labels": map[string]interface{}{
+				"app.kubernetes.io/managed-by": "Helm",
+			},
+			"annotations": map[string]interface{}{
+				"meta.helm.sh/release-name":      "other-release",
+				"meta.helm.sh/release-namespace": "other-ns",
+			},
+		},
+	}}
+
+	ok, _, err := evaluateResourcesOwnership(
+		[]*unstructured.Unstructured{alreadyAnnotated, ownedByOtherRelease},
+		rel, resourceID, AdoptionDisabled, nil, nil,
+	)
+	if ok {
+		t.Fatalf("expected evaluateResourcesOwnership to report the release as not fully annotated due to the ClusterRole conflict")
+	}
+	conflicts, isConflict := err.(ownershipConflicts)
+	if !isConflict {
+		t.Fatalf("expected an ownershipConflicts error, got %v (%T)", err, err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Name != "myrelease-role" {
+		t.Fatalf("expected a single conflict for the ClusterRole, got %#v", conflicts)
+	}
+}
+
+func TestEvaluateResourcesOwnership_AllMatch(t *testing.T) {
+	rel := &helm.Release{Name: "myrelease", Namespace: "myns"}
+	resourceID := resource.MakeID("myns", "helmrelease", "myrelease")
+
+	annotated := func(name string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": "myns",
+				"annotations": map[string]interface{}{
+					AntecedentAnnotation: resourceID.String(),
+				},
+			},
+		}}
+	}
+
+	ok, v, err := evaluateResourcesOwnership(
+		[]*unstructured.Unstructured{annotated("a"), annotated("b")},
+		rel, resourceID, AdoptionDisabled, nil, nil,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok || v != resourceID.String() {
+		t.Fatalf("expected (true, %q, nil), got (%v, %q, %v)", resourceID.String(), ok, v, err)
+	}
+}
+
+// TestSetCondition_PreservesLastTransitionTime checks the upsert
+// semantics that CheckAntecedent relies on to mirror conflicts onto
+// the HelmRelease's ResourceOwnershipConflict condition.
+func TestSetCondition_PreservesLastTransitionTime(t *testing.T) {
+	hr := &v1.HelmRelease{}
+
+	setCondition(hr, v1.HelmReleaseResourceOwnershipConflict, corev1.ConditionFalse, "", "")
+	if len(hr.Status.Conditions) != 1 {
+		t.Fatalf("expected a single condition, got %d", len(hr.Status.Conditions))
+	}
+	first := hr.Status.Conditions[0].LastTransitionTime
+
+	// Same status: the transition time must not move.
+	setCondition(hr, v1.HelmReleaseResourceOwnershipConflict, corev1.ConditionFalse, "", "")
+	if hr.Status.Conditions[0].LastTransitionTime != first {
+		t.Fatalf("LastTransitionTime changed even though status was unchanged")
+	}
+
+	// Status flips: the reason/message must update and there must
+	// still be exactly one condition of this type.
+	setCondition(hr, v1.HelmReleaseResourceOwnershipConflict, corev1.ConditionTrue, ReasonOwnershipConflict, "conflict!")
+	if len(hr.Status.Conditions) != 1 {
+		t.Fatalf("expected the existing condition to be updated in place, got %d conditions", len(hr.Status.Conditions))
+	}
+	if hr.Status.Conditions[0].Status != corev1.ConditionTrue || hr.Status.Conditions[0].Message != "conflict!" {
+		t.Fatalf("condition was not updated, got %#v", hr.Status.Conditions[0])
+	}
+}
+
+// TestEvaluateResourcesOwnership_MultipleConflictsReachTheCondition
+// exercises the same accumulation CheckAntecedent depends on to
+// populate the ResourceOwnershipConflict condition: every conflict
+// across the manifest, not just the first, must end up in the
+// ownershipConflicts error that CheckAntecedent mirrors onto status.
+func TestEvaluateResourcesOwnership_MultipleConflictsReachTheCondition(t *testing.T) {
+	rel := &helm.Release{Name: "myrelease", Namespace: "myns"}
+	resourceID := resource.MakeID("myns", "helmrelease", "myrelease")
+
+	conflicting := func(name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "rbac.authorization.k8s.io/v1",
+			"kind":       "ClusterRole",
+			"metadata": map[string]interface{}{
+				"name": name,
+				"labels": map[string]interface{}{
+					"app.kubernetes.io/managed-by": "Helm",
+				},
+				"annotations": map[string]interface{}{
+					"meta.helm.sh/release-name":      "other-release",
+					"meta.helm.sh/release-namespace": "other-ns",
+				},
+			},
+		}}
+		return u
+	}
+
+	_, _, err := evaluateResourcesOwnership(
+		[]*unstructured.Unstructured{conflicting("role-a"), conflicting("role-b")},
+		rel, resourceID, AdoptionDisabled, nil, nil,
+	)
+	conflicts, isConflict := err.(ownershipConflicts)
+	if !isConflict {
+		t.Fatalf("expected an ownershipConflicts error, got %v (%T)", err, err)
+	}
+	if len(conflicts) != 2 {
+		t.Fatalf("expected both conflicting ClusterRoles to be reported, got %#v", conflicts)
+	}
+	if conflicts[0].GroupVersionKind != (schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}) {
+		t.Fatalf("unexpected GroupVersionKind on conflict: %#v", conflicts[0].GroupVersionKind)
+	}
+
+	hr := &v1.HelmRelease{}
+	setCondition(hr, v1.HelmReleaseResourceOwnershipConflict, corev1.ConditionTrue, ReasonOwnershipConflict, conflicts.Error())
+	if hr.Status.Conditions[0].Message == "" {
+		t.Fatalf("expected the condition message to describe the conflicts")
+	}
+}
+
+func TestHookHasAnnotatedEvent(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		events []helmrelease.HookEvent
+		want   bool
+	}{
+		{"no events", nil, false},
+		{"single tracked event", []helmrelease.HookEvent{helmrelease.HookPreInstall}, true},
+		{"single untracked event", []helmrelease.HookEvent{helmrelease.HookPreUpgrade}, false},
+		{
+			name:   "tracked event combined with an untracked one, tracked first",
+			events: []helmrelease.HookEvent{helmrelease.HookPreInstall, helmrelease.HookPreUpgrade},
+			want:   true,
+		},
+		{
+			// This is the exact shape of the bug the previous
+			// hook.Events[0]-only check missed: a Job registered for
+			// both pre-upgrade and pre-install, with the untracked
+			// event listed first.
+			name:   "tracked event combined with an untracked one, tracked last",
+			events: []helmrelease.HookEvent{helmrelease.HookPreUpgrade, helmrelease.HookPreInstall},
+			want:   true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := &helmrelease.Hook{Events: tt.events}
+			if got := hookHasAnnotatedEvent(hook); got != tt.want {
+				t.Errorf("hookHasAnnotatedEvent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHookDeletedOnSuccess(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		policies []helmrelease.HookDeletePolicy
+		want     bool
+	}{
+		{"no delete policies", nil, false},
+		{"deleted before creation only", []helmrelease.HookDeletePolicy{helmrelease.HookBeforeHookCreation}, false},
+		{"deleted on success", []helmrelease.HookDeletePolicy{helmrelease.HookSucceeded}, true},
+		{
+			name:     "deleted on success combined with another policy",
+			policies: []helmrelease.HookDeletePolicy{helmrelease.HookBeforeHookCreation, helmrelease.HookSucceeded},
+			want:     true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := &helmrelease.Hook{DeletePolicies: tt.policies}
+			if got := hookDeletedOnSuccess(hook); got != tt.want {
+				t.Errorf("hookDeletedOnSuccess() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestApplyAntecedentConditions_ManifestInvalid checks that a plain
+// parse error from manifestsForRelease (as opposed to an
+// ownershipConflicts error) is mirrored onto the ManifestInvalid
+// condition, rather than being left invisible on the HelmRelease.
+func TestApplyAntecedentConditions_ManifestInvalid(t *testing.T) {
+	hr := &v1.HelmRelease{}
+
+	applyAntecedentConditions(hr, fmt.Errorf("parsing release manifest: %w", fmt.Errorf("yaml: line 3: did not find expected key")))
+
+	var found *v1.HelmReleaseCondition
+	for i := range hr.Status.Conditions {
+		if hr.Status.Conditions[i].Type == v1.HelmReleaseManifestInvalid {
+			found = &hr.Status.Conditions[i]
+		}
+	}
+	if found == nil || found.Status != corev1.ConditionTrue || found.Message == "" {
+		t.Fatalf("expected a True ManifestInvalid condition with a message, got %#v", hr.Status.Conditions)
+	}
+
+	// Once manifests parse again, the condition must clear.
+	applyAntecedentConditions(hr, nil)
+	for i := range hr.Status.Conditions {
+		if hr.Status.Conditions[i].Type == v1.HelmReleaseManifestInvalid && hr.Status.Conditions[i].Status != corev1.ConditionFalse {
+			t.Fatalf("expected ManifestInvalid to clear once the error is gone, got %#v", hr.Status.Conditions[i])
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package release
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+var (
+	discoveryCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "helm_operator_discovery_cache_hits_total",
+		Help: "Number of REST mapping lookups served from the cached discovery client.",
+	})
+	discoveryCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "helm_operator_discovery_cache_misses_total",
+		Help: "Number of REST mapping lookups that required invalidating the cached discovery client and re-querying the API server.",
+	})
+)
+
+// ClientPool holds a discovery-backed REST mapper and a dynamic client
+// built once from a `*rest.Config`, so that repeated antecedent
+// annotation operations against the same cluster don't each pay for a
+// fresh discovery round-trip and `dynamic.Interface` construction.
+//
+// A `ClientPool` is safe for concurrent use.
+type ClientPool struct {
+	config *rest.Config
+
+	mu              sync.Mutex
+	discoveryClient discovery.CachedDiscoveryInterface
+	restMapper      *restmapper.DeferredDiscoveryRESTMapper
+	dynamicClient   dynamic.Interface
+}
+
+// NewClientPool constructs a `ClientPool` for the given `rest.Config`.
+// It does not contact the API server until the pool is first used.
+func NewClientPool(kubeConfig *rest.Config) (*ClientPool, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	cachedDiscoveryClient := memory.NewMemCacheClient(discoveryClient)
+	return &ClientPool{
+		config:          kubeConfig,
+		discoveryClient: cachedDiscoveryClient,
+		restMapper:      restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscoveryClient),
+	}, nil
+}
+
+// RESTMapping resolves a `GroupKind`/version to a `RESTMapping`. On a
+// `meta.NoKindMatchError` — which typically means a CRD was installed
+// or a kind renamed since the mapper was last populated — it invalidates
+// the cache once and retries, so newly-registered kinds resolve without
+// requiring an operator restart.
+func (p *ClientPool) RESTMapping(gk schema.GroupKind, version string) (*meta.RESTMapping, error) {
+	p.mu.Lock()
+	mapper := p.restMapper
+	p.mu.Unlock()
+
+	mapping, err := mapper.RESTMapping(gk, version)
+	if err == nil {
+		discoveryCacheHits.Inc()
+		return mapping, nil
+	}
+	if !meta.IsNoMatchError(err) {
+		return nil, err
+	}
+
+	discoveryCacheMisses.Inc()
+	p.Reset()
+
+	p.mu.Lock()
+	mapper = p.restMapper
+	p.mu.Unlock()
+	return mapper.RESTMapping(gk, version)
+}
+
+// DynamicClient returns the pool's memoized `dynamic.Interface`,
+// constructing it on first use.
+func (p *ClientPool) DynamicClient() (dynamic.Interface, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.dynamicClient != nil {
+		return p.dynamicClient, nil
+	}
+	client, err := dynamic.NewForConfig(p.config)
+	if err != nil {
+		return nil, err
+	}
+	p.dynamicClient = client
+	return p.dynamicClient, nil
+}
+
+// Reset invalidates the cached discovery information, forcing the next
+// `RESTMapping` call to re-query the API server. Callers should invoke
+// this after installing CRDs mid-release so the newly-registered kinds
+// can be resolved without restarting the operator.
+func (p *ClientPool) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.discoveryClient.Invalidate()
+	p.restMapper.Reset()
+}